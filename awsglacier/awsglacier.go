@@ -12,6 +12,7 @@ import (
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/glacier"
+	"github.com/fatih/color"
 	ini "gopkg.in/ini.v1"
 )
 
@@ -25,6 +26,13 @@ const (
 	MB = 1024 * 1024
 )
 
+// Print renders this provider's display label.
+var Print = func() string { return color.CyanString("Amazon") }
+
+func init() {
+	cloud.RegisterProvider("amazon", New, Print)
+}
+
 func (svc *service) upload(ctx context.Context, file *os.File, name string) (*glacier.ArchiveCreationOutput, error) {
 	return svc.multiPartUpload(ctx, file, name)
 }
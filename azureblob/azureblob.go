@@ -0,0 +1,175 @@
+package azureblob
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/antontsv/backup/cloud"
+	"github.com/fatih/color"
+	ini "gopkg.in/ini.v1"
+)
+
+const (
+	// MB defines megabyte
+	MB = 1024 * 1024
+
+	defaultEndpointSuffix = "core.windows.net"
+)
+
+// Print renders this provider's display label.
+var Print = func() string { return color.BlueString("Azure") }
+
+func init() {
+	cloud.RegisterProvider("azure", New, Print)
+}
+
+type azureBackup struct {
+	ctx       context.Context
+	container azblob.ContainerURL
+	settings  map[string]string
+}
+
+// New returns a Backuper that works with Azure Blob Storage
+func New(ctx context.Context, bucketName string, cnf *ini.Section) (cloud.Backuper, error) {
+	values, err := cloud.GetKeyValues([]string{"accountName", "accountKey"}, cnf)
+	if err != nil {
+		return nil, err
+	}
+
+	endpointSuffix := defaultEndpointSuffix
+	if cnf.Haskey("endpointSuffix") {
+		if v := strings.TrimSpace(cnf.Key("endpointSuffix").Value()); v != "" {
+			endpointSuffix = v
+		}
+	}
+
+	credential, err := azblob.NewSharedKeyCredential(values["accountName"], values["accountKey"])
+	if err != nil {
+		return nil, fmt.Errorf("cannot create Azure credential: %v", err)
+	}
+
+	pipeline := azblob.NewPipeline(credential, azblob.PipelineOptions{})
+	serviceURL, err := url.Parse(fmt.Sprintf("https://%s.blob.%s", values["accountName"], endpointSuffix))
+	if err != nil {
+		return nil, fmt.Errorf("cannot build Azure service URL: %v", err)
+	}
+
+	container := azblob.NewServiceURL(*serviceURL, pipeline).NewContainerURL(bucketName)
+
+	_, err = container.Create(ctx, azblob.Metadata{}, azblob.PublicAccessNone)
+	if err != nil {
+		if storageErr, ok := err.(azblob.StorageError); !ok || storageErr.ServiceCode() != azblob.ServiceCodeContainerAlreadyExists {
+			return nil, fmt.Errorf("cannot create Azure storage container: %v", err)
+		}
+	}
+
+	return &azureBackup{
+		ctx:       ctx,
+		container: container,
+		settings:  values,
+	}, nil
+}
+
+// singleUpload uploads source as a single block blob, without staging any
+// blocks first. This covers files too small to be worth chunking, as well
+// as zero-byte files, which have no bytes to stage and would otherwise
+// leave CommitBlockList called with a never-staged block ID.
+func singleUpload(ctx context.Context, blob azblob.BlockBlobURL, source *os.File) error {
+	_, err := blob.Upload(ctx, source, azblob.BlobHTTPHeaders{}, azblob.Metadata{}, azblob.BlobAccessConditions{}, azblob.DefaultAccessTier, nil, azblob.ClientProvidedKeyOptions{}, azblob.ImmutabilityPolicyOptions{})
+	if err != nil {
+		return fmt.Errorf("error while finishing %s backup: %v", source.Name(), err)
+	}
+	return nil
+}
+
+func (b *azureBackup) Upload(ctx context.Context, file string, dest string) error {
+	source, err := os.Open(file)
+	if err != nil {
+		return fmt.Errorf("cannot open file %s in order to start backup into Azure Blob Storage: %v", file, err)
+	}
+	defer source.Close()
+
+	info, err := source.Stat()
+	if err != nil {
+		return fmt.Errorf("cannot open get %s file info: %v", file, err)
+	}
+
+	location := info.Name()
+
+	if strings.HasSuffix(dest, "/") {
+		location = fmt.Sprintf("%s%s", dest, location)
+	} else if dest != "." && len(dest) > 0 {
+		location = dest
+	}
+
+	blob := b.container.NewBlockBlobURL(location)
+	size := info.Size()
+	chunkSize := int64(4 * MB)
+	chunks := int(size / chunkSize)
+	if size%chunkSize != 0 {
+		chunks++
+	}
+
+	if chunks <= 1 {
+		return singleUpload(ctx, blob, source)
+	}
+
+	blockIDs := make([]string, chunks)
+	errorc := make(chan error)
+	sem := make(chan int, 10)
+	wg := &sync.WaitGroup{}
+
+	stageBlock := func(chunkNum int, start, end int64) {
+		defer wg.Done()
+		id := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%06d", chunkNum)))
+		blockIDs[chunkNum] = id
+		r := io.NewSectionReader(source, start, end-start+1)
+		_, err := blob.StageBlock(ctx, id, r, azblob.LeaseAccessConditions{}, nil, azblob.ClientProvidedKeyOptions{})
+		<-sem
+		if err != nil {
+			errorc <- err
+		}
+	}
+
+	for start, end, i := int64(0), chunkSize-1, 0; start < size; start, end, i = start+chunkSize, end+chunkSize, i+1 {
+		if end > size-1 {
+			end = size - 1
+		}
+		sem <- 1
+		wg.Add(1)
+		go stageBlock(i, start, end)
+	}
+	go func() {
+		wg.Wait()
+		close(errorc)
+	}()
+
+	errs := ""
+	for err := range errorc {
+		if err != nil {
+			errs = fmt.Sprintf("chunk error: %v; %s", err, errs)
+		}
+	}
+
+	if ctx.Err() == context.Canceled {
+		errs = "Operation was canceled"
+	}
+
+	if errs != "" {
+		return fmt.Errorf("%s", errs)
+	}
+
+	_, err = blob.CommitBlockList(ctx, blockIDs, azblob.BlobHTTPHeaders{}, azblob.Metadata{}, azblob.BlobAccessConditions{}, azblob.DefaultAccessTier, nil, azblob.ClientProvidedKeyOptions{}, azblob.ImmutabilityPolicyOptions{})
+	if err != nil {
+		return fmt.Errorf("error while finishing %s backup: %v", file, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,94 @@
+package b2
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/antontsv/backup/cloud"
+	"github.com/fatih/color"
+	"github.com/kurin/blazer/b2"
+	ini "gopkg.in/ini.v1"
+)
+
+// concurrentUploads controls how many chunks of a large file are sent to
+// Backblaze in parallel by the writer.
+const concurrentUploads = 4
+
+// Print renders this provider's display label.
+var Print = func() string { return color.RedString("Backblaze") }
+
+func init() {
+	cloud.RegisterProvider("backblaze", New, Print)
+}
+
+type backblazeBackup struct {
+	ctx      context.Context
+	bucket   *b2.Bucket
+	settings map[string]string
+}
+
+// New returns a Backuper that works with Backblaze B2
+func New(ctx context.Context, bucketName string, cnf *ini.Section) (cloud.Backuper, error) {
+	values, err := cloud.GetKeyValues([]string{"accountID", "applicationKey"}, cnf)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := b2.NewClient(ctx, values["accountID"], values["applicationKey"])
+	if err != nil {
+		return nil, fmt.Errorf("cannot create Backblaze B2 client: %v", err)
+	}
+
+	bucket, err := client.Bucket(ctx, bucketName)
+	if b2.IsNotExist(err) {
+		bucket, err = client.NewBucket(ctx, bucketName, nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot open Backblaze B2 bucket: %v", err)
+	}
+
+	return &backblazeBackup{
+		ctx:      ctx,
+		bucket:   bucket,
+		settings: values,
+	}, nil
+}
+
+func (b *backblazeBackup) Upload(ctx context.Context, file string, dest string) error {
+	source, err := os.Open(file)
+	if err != nil {
+		return fmt.Errorf("cannot open file %s in order to start backup into Backblaze B2: %v", file, err)
+	}
+	defer source.Close()
+
+	info, err := source.Stat()
+	if err != nil {
+		return fmt.Errorf("cannot open get %s file info: %v", file, err)
+	}
+
+	location := info.Name()
+
+	if strings.HasSuffix(dest, "/") {
+		location = fmt.Sprintf("%s%s", dest, location)
+	} else if dest != "." && len(dest) > 0 {
+		location = dest
+	}
+
+	obj := b.bucket.Object(location)
+	w := obj.NewWriter(ctx)
+	w.ConcurrentUploads = concurrentUploads
+
+	if _, err := io.Copy(w, source); err != nil {
+		w.Close()
+		return fmt.Errorf("error during %s backup: %v", file, err)
+	}
+
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("error while finishing %s backup: %v", file, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,325 @@
+package cloud
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	encMagic   = "BKUP"
+	encVersion = byte(1)
+	nonceSize  = 12
+	keySize    = 32
+	saltSize   = 16
+
+	scryptN = 32768
+	scryptR = 8
+	scryptP = 1
+)
+
+type encryptedBackup struct {
+	inner Backuper
+	key   []byte
+}
+
+// Encrypted wraps inner so that every file is transparently encrypted with
+// AES-256-GCM before being handed to inner's Upload. Objects are written as
+// magic(4) || version(1) || nonce(12) || ciphertext, where ciphertext
+// already carries its GCM authentication tag.
+func Encrypted(inner Backuper, key []byte) Backuper {
+	return &encryptedBackup{inner: inner, key: key}
+}
+
+func (e *encryptedBackup) Upload(ctx context.Context, file string, dest string) error {
+	tmp, err := encryptToFile(file, e.key)
+	if err != nil {
+		return fmt.Errorf("cannot encrypt %s: %v", file, err)
+	}
+
+	if err := e.inner.Upload(ctx, tmp, resolveDest(file, dest)); err != nil {
+		// Leave the encrypted temp file in place on failure: it is the
+		// only way an inner resumable uploader (see gpcs) can recognize a
+		// retry and pick up where it left off, instead of starting over
+		// with a freshly encrypted (and differently nonced) object.
+		return err
+	}
+
+	releaseEncrypted(tmp)
+	return nil
+}
+
+// resolveDest mirrors the destination-naming rules the gpcs, b2 and
+// azureblob providers' Upload already applies, so that a temporary,
+// encrypted file's own name never leaks into the uploaded object's name.
+func resolveDest(file, dest string) string {
+	name := filepath.Base(file)
+	if strings.HasSuffix(dest, "/") {
+		return dest + name
+	}
+	if dest != "." && dest != "" {
+		return dest
+	}
+	return name
+}
+
+// encryptToFile encrypts source with a fresh, random nonce and returns the
+// path of the resulting ciphertext file. The path is derived from source's
+// own (absolute) path, not its content, purely so that a retry after a
+// failed upload finds the same, already-encrypted file left behind by
+// Upload rather than encrypting from scratch with a brand new nonce; it
+// plays no part in choosing the nonce itself, so re-uploading the same
+// content on a later, unrelated run still gets a fresh one.
+//
+// The path is reference-counted via acquireEncrypted / releaseEncrypted,
+// so two backends encrypting the same source file at the same time share
+// one in-flight ciphertext instead of racing to write or delete it.
+func encryptToFile(source string, key []byte) (string, error) {
+	path, err := pendingEncryptedPath(source)
+	if err != nil {
+		return "", err
+	}
+	acquireEncrypted(path)
+
+	encWriteMu.Lock()
+	defer encWriteMu.Unlock()
+
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	} else if !os.IsNotExist(err) {
+		releaseEncrypted(path)
+		return "", err
+	}
+
+	if err := writeEncrypted(source, key, path); err != nil {
+		releaseEncrypted(path)
+		return "", err
+	}
+
+	return path, nil
+}
+
+// pendingEncryptedPath returns the path an in-progress encrypted copy of
+// source is kept at until its upload succeeds.
+func pendingEncryptedPath(source string) (string, error) {
+	abs, err := filepath.Abs(source)
+	if err != nil {
+		return "", err
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(home, ".backup", "encrypted")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+
+	id := sha256.Sum256([]byte(abs))
+	return filepath.Join(dir, hex.EncodeToString(id[:])), nil
+}
+
+// writeEncrypted encrypts source with a random nonce, then atomically
+// publishes the result at path so that a concurrent reader never observes
+// a partially written file.
+func writeEncrypted(source string, key []byte, path string) error {
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, nonceSize)
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return err
+	}
+
+	plaintext, err := io.ReadAll(in)
+	if err != nil {
+		return err
+	}
+	ciphertext := gcm.Seal(nil, nonce, plaintext, nil)
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(append([]byte(encMagic), encVersion)); err == nil {
+		if _, err = tmp.Write(nonce); err == nil {
+			_, err = tmp.Write(ciphertext)
+		}
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+var (
+	encRefsMu sync.Mutex
+	encRefs   = make(map[string]int)
+
+	// encWriteMu serializes encryptToFile's check-then-write, so only the
+	// first of several concurrent callers encrypts and the rest reuse it.
+	encWriteMu sync.Mutex
+)
+
+// acquireEncrypted marks path as in use, so concurrent backends encrypting
+// the same source file share one encrypted copy instead of racing to
+// write or delete it out from under each other.
+func acquireEncrypted(path string) {
+	encRefsMu.Lock()
+	encRefs[path]++
+	encRefsMu.Unlock()
+}
+
+// releaseEncrypted drops a reference taken by acquireEncrypted, removing
+// the encrypted copy once nothing else is using it.
+func releaseEncrypted(path string) {
+	encRefsMu.Lock()
+	encRefs[path]--
+	done := encRefs[path] <= 0
+	if done {
+		delete(encRefs, path)
+	}
+	encRefsMu.Unlock()
+	if done {
+		os.Remove(path)
+	}
+}
+
+// Decrypt reverses the format written by encryptToFile: it reads the
+// encrypted object at source and writes its plaintext to dest. It is used
+// by the backup-decrypt companion command.
+func Decrypt(source, dest string, key []byte) error {
+	raw, err := os.ReadFile(source)
+	if err != nil {
+		return err
+	}
+
+	headerSize := len(encMagic) + 1 + nonceSize
+	if len(raw) < headerSize {
+		return fmt.Errorf("%s is too short to be an encrypted backup object", source)
+	}
+	if string(raw[:len(encMagic)]) != encMagic {
+		return fmt.Errorf("%s does not have the expected encrypted backup header", source)
+	}
+	if raw[len(encMagic)] != encVersion {
+		return fmt.Errorf("%s was encrypted with unsupported format version %d", source, raw[len(encMagic)])
+	}
+
+	nonce := raw[len(encMagic)+1 : headerSize]
+	ciphertext := raw[headerSize:]
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return fmt.Errorf("cannot decrypt %s: %v", source, err)
+	}
+
+	return os.WriteFile(dest, plaintext, 0600)
+}
+
+// DeriveKey derives a 32-byte AES-256 key from a passphrase and a
+// bucket-scoped salt using scrypt.
+func DeriveKey(passphrase string, salt []byte) ([]byte, error) {
+	return scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, keySize)
+}
+
+// BucketSalt returns the persistent, bucket-scoped salt used to derive the
+// encryption key, creating one under ~/.backup/ the first time it is
+// needed. Reusing the same salt for a bucket across runs is what makes the
+// derived key, and therefore decryption, reproducible.
+//
+// The salt file is local state, not part of the backup itself: to restore
+// or decrypt objects on another machine, copy the salt file for the
+// bucket (~/.backup/salt-<bucket>) there alongside the passphrase, or use
+// LoadBucketSalt to fail loudly instead of silently deriving the wrong key.
+func BucketSalt(bucket string) ([]byte, error) {
+	salt, err := LoadBucketSalt(bucket)
+	if err == nil {
+		return salt, nil
+	}
+
+	path, pathErr := saltPath(bucket)
+	if pathErr != nil {
+		return nil, pathErr
+	}
+
+	salt = make([]byte, saltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, salt, 0600); err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
+
+// LoadBucketSalt reads the persistent, bucket-scoped salt for bucket,
+// failing instead of generating a new one when none exists yet. Use this
+// to restore or decrypt objects, where silently deriving an unrelated key
+// from a freshly generated salt would otherwise only surface much later,
+// as an opaque decryption failure.
+func LoadBucketSalt(bucket string) ([]byte, error) {
+	path, err := saltPath(bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	salt, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no salt file found for bucket %s at %s; restore this salt file from the machine that created these backups before decrypting", bucket, path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
+
+func saltPath(bucket string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(home, ".backup")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, fmt.Sprintf("salt-%s", bucket)), nil
+}
@@ -0,0 +1,198 @@
+package cloud
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type recordingBackuper struct {
+	file string
+	dest string
+	data []byte
+}
+
+// Upload captures the encrypted bytes before returning, mirroring how a
+// real provider reads the file during its own Upload call: the caller
+// removes the encrypted temp file as soon as Upload returns successfully.
+func (r *recordingBackuper) Upload(ctx context.Context, file string, dest string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	r.file = file
+	r.dest = dest
+	r.data = data
+	return nil
+}
+
+func TestEncryptedUploadDecryptRoundTrip(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	src := filepath.Join(t.TempDir(), "plain.txt")
+	want := []byte("hello, backup")
+	if err := os.WriteFile(src, want, 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	key := make([]byte, keySize)
+	for i := range key {
+		key[i] = byte(i)
+	}
+
+	inner := &recordingBackuper{}
+	enc := Encrypted(inner, key)
+	if err := enc.Upload(context.Background(), src, "dest/"); err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+
+	if inner.dest != "dest/plain.txt" {
+		t.Errorf("expected inner Upload to see dest 'dest/plain.txt', got %q", inner.dest)
+	}
+	if inner.file == src {
+		t.Errorf("expected inner Upload to receive an encrypted temp file, not the plaintext source")
+	}
+
+	encrypted := filepath.Join(t.TempDir(), "captured.enc")
+	if err := os.WriteFile(encrypted, inner.data, 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	out := filepath.Join(t.TempDir(), "decrypted.txt")
+	if err := Decrypt(encrypted, out, key); err != nil {
+		t.Fatalf("Decrypt: %v", err)
+	}
+
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("expected decrypted content %q, got %q", want, got)
+	}
+}
+
+func TestEncryptToFileUsesRandomNonce(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	src := filepath.Join(t.TempDir(), "plain.txt")
+	if err := os.WriteFile(src, []byte("same content, twice"), 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	key := make([]byte, keySize)
+
+	path1, err := encryptToFile(src, key)
+	if err != nil {
+		t.Fatalf("encryptToFile: %v", err)
+	}
+	ct1, err := os.ReadFile(path1)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	releaseEncrypted(path1)
+
+	path2, err := encryptToFile(src, key)
+	if err != nil {
+		t.Fatalf("encryptToFile: %v", err)
+	}
+	ct2, err := os.ReadFile(path2)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	releaseEncrypted(path2)
+
+	if string(ct1) == string(ct2) {
+		t.Errorf("expected two independent encryptions of identical content to differ (random nonce), got byte-identical ciphertexts")
+	}
+}
+
+func TestEncryptToFileReusesPendingFileOnRetry(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	src := filepath.Join(t.TempDir(), "plain.txt")
+	if err := os.WriteFile(src, []byte("in flight"), 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	key := make([]byte, keySize)
+
+	path, err := encryptToFile(src, key)
+	if err != nil {
+		t.Fatalf("encryptToFile: %v", err)
+	}
+	ct1, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	// Simulate a failed upload: the caller does not release/remove the
+	// encrypted file, leaving it in place for a retry to find.
+
+	path2, err := encryptToFile(src, key)
+	if err != nil {
+		t.Fatalf("encryptToFile (retry): %v", err)
+	}
+	if path2 != path {
+		t.Errorf("expected a retry to reuse the same pending path, got %q then %q", path, path2)
+	}
+	ct2, err := os.ReadFile(path2)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if string(ct1) != string(ct2) {
+		t.Errorf("expected a retry to reuse the same ciphertext bytes instead of re-encrypting")
+	}
+
+	releaseEncrypted(path)
+	releaseEncrypted(path2)
+}
+
+func TestDeriveKeyDeterministic(t *testing.T) {
+	salt := []byte("0123456789abcdef")
+
+	k1, err := DeriveKey("hunter2", salt)
+	if err != nil {
+		t.Fatalf("DeriveKey: %v", err)
+	}
+	k2, err := DeriveKey("hunter2", salt)
+	if err != nil {
+		t.Fatalf("DeriveKey: %v", err)
+	}
+	if string(k1) != string(k2) {
+		t.Errorf("expected DeriveKey to be deterministic for the same passphrase and salt")
+	}
+
+	k3, err := DeriveKey("different", salt)
+	if err != nil {
+		t.Fatalf("DeriveKey: %v", err)
+	}
+	if string(k1) == string(k3) {
+		t.Errorf("expected DeriveKey to differ for a different passphrase")
+	}
+}
+
+func TestLoadBucketSaltMissing(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	if _, err := LoadBucketSalt("no-such-bucket"); err == nil {
+		t.Errorf("expected LoadBucketSalt to fail loudly when no salt file exists")
+	}
+}
+
+func TestBucketSaltPersistsAndLoadBucketSaltFindsIt(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	created, err := BucketSalt("my-bucket")
+	if err != nil {
+		t.Fatalf("BucketSalt: %v", err)
+	}
+
+	loaded, err := LoadBucketSalt("my-bucket")
+	if err != nil {
+		t.Fatalf("LoadBucketSalt: %v", err)
+	}
+	if string(created) != string(loaded) {
+		t.Errorf("expected LoadBucketSalt to return the same salt BucketSalt persisted")
+	}
+}
@@ -0,0 +1,55 @@
+package cloud
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	ini "gopkg.in/ini.v1"
+)
+
+// Factory constructs a Backuper for a registered provider.
+type Factory func(ctx context.Context, bucket string, cnf *ini.Section) (Backuper, error)
+
+type provider struct {
+	factory Factory
+	label   func() string
+}
+
+var providers = make(map[string]*provider)
+
+// RegisterProvider makes a cloud backup provider available under name.
+// Provider packages call this from an init() function so that main
+// does not need to know about any specific provider.
+func RegisterProvider(name string, factory Factory, labelFn func() string) {
+	providers[name] = &provider{factory: factory, label: labelFn}
+}
+
+// ProviderNames returns the names of all registered providers, sorted
+// alphabetically.
+func ProviderNames() []string {
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewProvider constructs a Backuper for the named provider.
+func NewProvider(ctx context.Context, name string, bucket string, cnf *ini.Section) (Backuper, error) {
+	p, ok := providers[name]
+	if !ok {
+		return nil, fmt.Errorf("cloud provider '%s' is not registered", name)
+	}
+	return p.factory(ctx, bucket, cnf)
+}
+
+// ProviderLabel returns the display label for the named provider.
+func ProviderLabel(name string) string {
+	p, ok := providers[name]
+	if !ok {
+		return name
+	}
+	return p.label()
+}
@@ -0,0 +1,51 @@
+package cloud
+
+import (
+	"context"
+	"testing"
+
+	ini "gopkg.in/ini.v1"
+)
+
+func TestRegisterAndLookupProvider(t *testing.T) {
+	const name = "test-provider"
+	stub := &struct{ Backuper }{}
+
+	RegisterProvider(name, func(ctx context.Context, bucket string, cnf *ini.Section) (Backuper, error) {
+		return stub, nil
+	}, func() string { return "Test Provider" })
+
+	found := false
+	for _, n := range ProviderNames() {
+		if n == name {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected %q to be listed in ProviderNames", name)
+	}
+
+	if label := ProviderLabel(name); label != "Test Provider" {
+		t.Errorf("expected label 'Test Provider', got %q", label)
+	}
+
+	bak, err := NewProvider(context.Background(), name, "bucket", nil)
+	if err != nil {
+		t.Fatalf("NewProvider: %v", err)
+	}
+	if bak != stub {
+		t.Errorf("expected NewProvider to return the registered factory's Backuper")
+	}
+}
+
+func TestProviderLabelUnknown(t *testing.T) {
+	if label := ProviderLabel("does-not-exist"); label != "does-not-exist" {
+		t.Errorf("expected ProviderLabel to fall back to the name itself, got %q", label)
+	}
+}
+
+func TestNewProviderUnknown(t *testing.T) {
+	if _, err := NewProvider(context.Background(), "does-not-exist", "bucket", nil); err == nil {
+		t.Errorf("expected NewProvider to error for an unregistered provider")
+	}
+}
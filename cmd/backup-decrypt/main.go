@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/antontsv/backup/cloud"
+	ini "gopkg.in/ini.v1"
+)
+
+var usage = func() {
+	fmt.Fprintf(os.Stderr, "usage %s [-c config] -bucket bucketName encryptedFile outputFile\n", os.Args[0])
+	flag.PrintDefaults()
+}
+
+func main() {
+	creds := flag.String("c", "backup.ini", "File with cloud account config")
+	bucket := flag.String("bucket", "", "Bucket name the file was encrypted for, to select its persisted salt. "+
+		"The salt file (~/.backup/salt-<bucket>) must be restored from the machine that created the backup before decrypting elsewhere")
+	flag.CommandLine.Usage = usage
+	flag.Parse()
+
+	if *bucket == "" || flag.NArg() < 2 {
+		usage()
+		log.Fatalln("Missing bucket name, encrypted file, or output file")
+	}
+
+	cfg, err := ini.InsensitiveLoad(*creds)
+	if err != nil {
+		log.Fatalf("Cannot read credentials file %s: %v\n", *creds, err)
+	}
+
+	if !cfg.HasSection("encryption") || !cfg.Section("encryption").HasKey("passphrase") {
+		log.Fatalln("Missing [encryption] passphrase entry in config")
+	}
+	passphrase := strings.TrimSpace(cfg.Section("encryption").Key("passphrase").Value())
+	if passphrase == "" {
+		log.Fatalln("[encryption] passphrase entry in config is empty")
+	}
+
+	salt, err := cloud.LoadBucketSalt(*bucket)
+	if err != nil {
+		log.Fatalf("Cannot load encryption salt for bucket %s: %v", *bucket, err)
+	}
+
+	key, err := cloud.DeriveKey(passphrase, salt)
+	if err != nil {
+		log.Fatalf("Cannot derive encryption key: %v", err)
+	}
+
+	source := flag.Arg(0)
+	dest := flag.Arg(1)
+
+	if err := cloud.Decrypt(source, dest, key); err != nil {
+		log.Fatalf("Cannot decrypt %s: %v", source, err)
+	}
+}
@@ -6,15 +6,39 @@ import (
 	"io"
 	"os"
 	"strings"
+	"sync"
 
 	"github.com/antontsv/backup/cloud"
+	"github.com/antontsv/backup/state"
+	"github.com/fatih/color"
 	"google.golang.org/api/iterator"
 	ini "gopkg.in/ini.v1"
 
 	"cloud.google.com/go/storage"
 )
 
-const storageClass = "COLDLINE"
+const (
+	storageClass = "COLDLINE"
+
+	// MB defines megabyte
+	MB = 1024 * 1024
+
+	// chunkThreshold is the file size above which Upload splits a file
+	// into chunks instead of sending it as a single object.
+	chunkThreshold = 16 * MB
+	chunkSize      = 16 * MB
+
+	// maxComposeParts is the number of source objects the GCS Compose API
+	// accepts in a single call.
+	maxComposeParts = 32
+)
+
+// Print renders this provider's display label.
+var Print = func() string { return color.YellowString("Google") }
+
+func init() {
+	cloud.RegisterProvider("google", New, Print)
+}
 
 type googleBackup struct {
 	ctx      context.Context
@@ -78,6 +102,8 @@ func (b *googleBackup) Upload(ctx context.Context, file string, dest string) err
 	if err != nil {
 		return fmt.Errorf("cannot open file %s in order to start backup into GPC: %v", file, err)
 	}
+	defer source.Close()
+
 	info, err := source.Stat()
 	if err != nil {
 		return fmt.Errorf("cannot open get %s file info: %v", file, err)
@@ -91,26 +117,135 @@ func (b *googleBackup) Upload(ctx context.Context, file string, dest string) err
 		location = dest
 	}
 
-	f := b.handle.Object(location)
-	w := f.NewWriter(ctx)
+	if info.Size() <= chunkThreshold {
+		return b.uploadSingle(ctx, source, location)
+	}
+
+	return b.uploadChunked(ctx, source, info, location)
+}
+
+func (b *googleBackup) uploadSingle(ctx context.Context, source *os.File, location string) error {
+	w := b.handle.Object(location).NewWriter(ctx)
+
+	if _, err := io.Copy(w, source); err != nil {
+		w.Close()
+		return fmt.Errorf("error during %s backup: %v", source.Name(), err)
+	}
+
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("error while finishing %s backup: %v", source.Name(), err)
+	}
+
+	return nil
+}
+
+// uploadChunked splits a large file into chunkSize parts, uploads each as
+// its own temporary object with up to 10 in flight at a time (matching the
+// sem cap used by awsglacier's multipart upload), then stitches the parts
+// together with the GCS Compose API and removes them. Progress is
+// persisted in a resume sidecar, so a re-run after a cancellation only
+// uploads the parts that are still missing.
+func (b *googleBackup) uploadChunked(ctx context.Context, source *os.File, info os.FileInfo, location string) error {
+	size := info.Size()
+	chunks := int(size / chunkSize)
+	if size%chunkSize != 0 {
+		chunks++
+	}
+	if chunks > maxComposeParts {
+		return fmt.Errorf("%s is too large to upload in %d chunks: GCS Compose supports at most %d source objects", source.Name(), chunks, maxComposeParts)
+	}
+
+	hash, err := state.Hash(source.Name())
+	if err != nil {
+		return fmt.Errorf("cannot hash %s: %v", source.Name(), err)
+	}
 
-	buf := make([]byte, 1024)
-	for {
-		n, err := source.Read(buf)
-		if n > 0 {
-			_, err = w.Write(buf[0:n])
+	resumeKey := b.bucket + "/" + location
+	rs, err := loadResume(resumeKey)
+	if err != nil {
+		return fmt.Errorf("cannot read resume state for %s: %v", location, err)
+	}
+	if rs.SourcePath != source.Name() || rs.SHA256 != hash || rs.ChunkSize != chunkSize {
+		rs = &resumeState{SourcePath: source.Name(), SHA256: hash, ChunkSize: chunkSize}
+	}
+
+	partName := func(i int) string {
+		return fmt.Sprintf("%s.part%d", location, i)
+	}
+
+	type partResult struct {
+		idx int
+		err error
+	}
+
+	results := make(chan partResult)
+	sem := make(chan int, 10)
+	wg := &sync.WaitGroup{}
+
+	uploadPart := func(i int, start, end int64) {
+		defer wg.Done()
+		r := io.NewSectionReader(source, start, end-start+1)
+		w := b.handle.Object(partName(i)).NewWriter(ctx)
+		_, err := io.Copy(w, r)
+		if err == nil {
+			err = w.Close()
+		} else {
+			w.Close()
 		}
-		if err == io.EOF {
-			break
+		<-sem
+		results <- partResult{idx: i, err: err}
+	}
+
+	for start, i := int64(0), 0; start < size; start, i = start+chunkSize, i+1 {
+		if rs.hasPart(i) {
+			continue
 		}
-		if err != nil {
-			return fmt.Errorf("error during %s backup: %v", file, err)
+		end := start + chunkSize - 1
+		if end > size-1 {
+			end = size - 1
+		}
+		sem <- 1
+		wg.Add(1)
+		go uploadPart(i, start, end)
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	errs := ""
+	for res := range results {
+		if res.err != nil {
+			errs = fmt.Sprintf("chunk %d error: %v; %s", res.idx, res.err, errs)
+			continue
+		}
+		rs.UploadedParts = append(rs.UploadedParts, res.idx)
+		if err := rs.save(resumeKey); err != nil {
+			errs = fmt.Sprintf("cannot save resume state: %v; %s", err, errs)
 		}
 	}
 
-	err = w.Close()
-	if err != nil {
-		return fmt.Errorf("error while finishing %s backup: %v", file, err)
+	if ctx.Err() == context.Canceled {
+		errs = "Operation was canceled"
+	}
+
+	if errs != "" {
+		return fmt.Errorf("%s", errs)
+	}
+
+	srcs := make([]*storage.ObjectHandle, chunks)
+	for i := 0; i < chunks; i++ {
+		srcs[i] = b.handle.Object(partName(i))
+	}
+
+	composer := b.handle.Object(location).ComposerFrom(srcs...)
+	composer.DeleteSourceObjects = true
+	if _, err := composer.Run(ctx); err != nil {
+		return fmt.Errorf("cannot compose %s from %d parts: %v", location, chunks, err)
+	}
+
+	if err := rs.remove(resumeKey); err != nil {
+		return fmt.Errorf("cannot remove resume state for %s: %v", location, err)
 	}
 
 	return nil
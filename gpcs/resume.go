@@ -0,0 +1,86 @@
+package gpcs
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// resumeState tracks progress of a chunked upload so that a re-run after
+// a cancellation only finishes what is left, instead of starting over.
+type resumeState struct {
+	SourcePath    string `json:"sourcePath"`
+	SHA256        string `json:"sha256"`
+	ChunkSize     int64  `json:"chunkSize"`
+	UploadedParts []int  `json:"uploadedParts"`
+}
+
+func resumePath(key string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(home, ".backup", "resume")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	name := strings.ReplaceAll(key, "/", "_")
+	return filepath.Join(dir, name+".json"), nil
+}
+
+// loadResume reads the sidecar file for key, returning an empty state if
+// none exists yet.
+func loadResume(key string) (*resumeState, error) {
+	path, err := resumePath(key)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &resumeState{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var rs resumeState
+	if err := json.Unmarshal(b, &rs); err != nil {
+		return nil, err
+	}
+	return &rs, nil
+}
+
+func (rs *resumeState) save(key string) error {
+	path, err := resumePath(key)
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(rs, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0600)
+}
+
+func (rs *resumeState) remove(key string) error {
+	path, err := resumePath(key)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (rs *resumeState) hasPart(i int) bool {
+	for _, p := range rs.UploadedParts {
+		if p == i {
+			return true
+		}
+	}
+	return false
+}
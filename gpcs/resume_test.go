@@ -0,0 +1,57 @@
+package gpcs
+
+import (
+	"os"
+	"testing"
+)
+
+func TestResumeStateSaveLoadRemove(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	key := "bucket/some/object"
+
+	rs, err := loadResume(key)
+	if err != nil {
+		t.Fatalf("loadResume: %v", err)
+	}
+	if rs.hasPart(0) {
+		t.Errorf("expected a fresh resume state to have no parts")
+	}
+
+	rs = &resumeState{SourcePath: "/tmp/file", SHA256: "abc", ChunkSize: 16, UploadedParts: []int{0, 2}}
+	if err := rs.save(key); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	reloaded, err := loadResume(key)
+	if err != nil {
+		t.Fatalf("loadResume after save: %v", err)
+	}
+	if reloaded.SourcePath != rs.SourcePath || reloaded.SHA256 != rs.SHA256 || reloaded.ChunkSize != rs.ChunkSize {
+		t.Errorf("expected reloaded resume state to match saved state, got %+v", reloaded)
+	}
+	if !reloaded.hasPart(0) || !reloaded.hasPart(2) {
+		t.Errorf("expected reloaded resume state to have parts 0 and 2")
+	}
+	if reloaded.hasPart(1) {
+		t.Errorf("expected reloaded resume state to not have part 1")
+	}
+
+	if err := reloaded.remove(key); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+
+	path, err := resumePath(key)
+	if err != nil {
+		t.Fatalf("resumePath: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected resume sidecar to be removed, stat err: %v", err)
+	}
+
+	// Removing an already-removed sidecar is a no-op, not an error.
+	if err := reloaded.remove(key); err != nil {
+		t.Errorf("expected remove to tolerate a missing sidecar, got: %v", err)
+	}
+}
@@ -13,17 +13,56 @@ import (
 	"sync"
 	"time"
 
-	"github.com/antontsv/backup/awsglacier"
 	"github.com/antontsv/backup/cloud"
-	"github.com/antontsv/backup/gpcs"
+	"github.com/antontsv/backup/state"
+
+	// Providers register themselves with the cloud package on import.
+	_ "github.com/antontsv/backup/awsglacier"
+	_ "github.com/antontsv/backup/azureblob"
+	_ "github.com/antontsv/backup/b2"
+	_ "github.com/antontsv/backup/gpcs"
+
 	ini "gopkg.in/ini.v1"
 )
 
 var usage = func() {
 	fmt.Fprintf(os.Stderr, "usage %s [-r] [-c config] file1 ... bucketName:[/path] \n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s -auto -watch dir [-interval duration] [-c config] bucketName:[/path] \n", os.Args[0])
 	flag.PrintDefaults()
 }
 
+// backend is a provider selected for this run, paired with its display
+// label and the plain name used to namespace upload state.
+type backend struct {
+	name  string
+	label string
+	svc   cloud.Backuper
+}
+
+// parseDest splits a destination argument of the form bucketName:[/path]
+// into the bucket name and the path. A trailing slash on the input path
+// is preserved so that providers can tell a directory destination
+// ("some/dir/") apart from an exact file name ("some/dir/name.txt").
+func parseDest(arg string) (bucket string, path string) {
+	dest := strings.SplitN(arg, ":", 2)
+	bucket = dest[0]
+
+	if len(dest) < 2 {
+		return bucket, ""
+	}
+
+	trimmed := strings.Trim(dest[1], "/")
+	if trimmed == "" {
+		return bucket, ""
+	}
+
+	path = trimmed
+	if strings.HasSuffix(dest[1], "/") {
+		path += "/"
+	}
+	return bucket, path
+}
+
 func main() {
 
 	type config struct {
@@ -31,27 +70,38 @@ func main() {
 		ini      *ini.Section
 	}
 
-	providers := map[string]*config{
-		"amazon": {},
-		"google": {},
-	}
-
-	names := make([]string, 0, len(providers))
-	for name := range providers {
-		names = append(names, name)
+	names := cloud.ProviderNames()
+	providers := make(map[string]*config, len(names))
+	for _, name := range names {
+		providers[name] = &config{}
 	}
 
 	provider := flag.String("p", strings.Join(names, ","), fmt.Sprintf("Cloud service provider names to use"))
 	creds := flag.String("c", "backup.ini", "File with cloud account config")
 	recursive := flag.Bool("r", false, "Recursively backup entire directories")
+	auto := flag.Bool("auto", false, "Run continuously, periodically re-scanning sources and uploading new or changed files")
+	interval := flag.Duration("interval", 24*time.Hour, "How often to rescan sources in -auto mode")
+	watch := flag.String("watch", "", "Directory to watch for changes in -auto mode, in place of source arguments")
 	flag.CommandLine.Usage = usage
 	flag.Parse()
 
 	num := flag.NArg()
-	if num < 1 || flag.Arg(0) == "" {
-		log.Fatalln("Missing source file/directory. This must be specified as a first parameter")
-	} else if num < 2 {
-		log.Fatalln("Missing target destination. This must be specified as a second parameter as follows 'bucketName:/some/optional/path'")
+	var sources []string
+	recurseSources := *recursive
+
+	if *auto && *watch != "" {
+		if num < 1 || flag.Arg(0) == "" {
+			log.Fatalln("Missing target destination. This must be specified as a parameter as follows 'bucketName:/some/optional/path'")
+		}
+		sources = []string{*watch}
+		recurseSources = true
+	} else {
+		if num < 1 || flag.Arg(0) == "" {
+			log.Fatalln("Missing source file/directory. This must be specified as a first parameter")
+		} else if num < 2 {
+			log.Fatalln("Missing target destination. This must be specified as a second parameter as follows 'bucketName:/some/optional/path'")
+		}
+		sources = flag.Args()[0 : num-1]
 	}
 
 	cfg, err := ini.InsensitiveLoad(*creds)
@@ -76,20 +126,7 @@ func main() {
 		}
 	}
 
-	var parts []string
-
-	dest := strings.SplitN(flag.Arg(num-1), ":", 2)
-	bucket := dest[0]
-
-	if len(dest) > 1 {
-		for _, part := range strings.Split(dest[1], "/") {
-			if part != "" {
-				parts = append(parts, part)
-			}
-		}
-	}
-
-	path := strings.TrimPrefix(strings.Join(parts, "/")+"/", "/")
+	bucket, path := parseDest(flag.Arg(num - 1))
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -102,48 +139,91 @@ func main() {
 		cancel()
 	}()
 
-	files := make(chan string)
-	go walkSources(ctx, flag.Args()[0:num-1], *recursive, files)
+	var encryptKey []byte
+	if cfg.HasSection("encryption") {
+		sec := cfg.Section("encryption")
+		if sec.HasKey("passphrase") {
+			passphrase := strings.TrimSpace(sec.Key("passphrase").Value())
+			if passphrase == "" {
+				log.Fatalln("[encryption] passphrase entry in config is empty")
+			}
+			salt, err := cloud.BucketSalt(bucket)
+			if err != nil {
+				log.Fatalf("Cannot load encryption salt for bucket %s: %v", bucket, err)
+			}
+			encryptKey, err = cloud.DeriveKey(passphrase, salt)
+			if err != nil {
+				log.Fatalf("Cannot derive encryption key: %v", err)
+			}
+		}
+	}
 
-	backupers := make(map[string]cloud.Backuper)
-	doBackup := false
+	var backupers []backend
 	for name, cnf := range providers {
 		if cnf.selected && cnf.ini != nil {
-			switch name {
-			case "google":
-				gpc, err := gpcs.New(ctx, bucket, cnf.ini)
-				if err != nil {
-					log.Fatalf("Cannot init Google backup: %v", err)
-				}
-				backupers["Google"] = gpc
-			case "amazon":
-				glacier, err := awsglacier.New(ctx, bucket, cnf.ini)
-				if err != nil {
-					log.Fatalf("Cannot init Amazon backup: %v", err)
-				}
-				backupers["Amazon"] = glacier
+			bak, err := cloud.NewProvider(ctx, name, bucket, cnf.ini)
+			if err != nil {
+				log.Fatalf("Cannot init %s backup: %v", name, err)
+			}
+			if encryptKey != nil {
+				bak = cloud.Encrypted(bak, encryptKey)
 			}
-			doBackup = true
+			backupers = append(backupers, backend{name: name, label: cloud.ProviderLabel(name), svc: bak})
 		}
 	}
-	if !doBackup {
+	if len(backupers) == 0 {
 		log.Fatalln("No backup can be done because no cloud providers are configured")
 	}
 
-	wg := &sync.WaitGroup{}
-	for f := range files {
-		statuses := make(map[string]chan string)
-		for name, bak := range backupers {
+	statePath, err := state.DefaultPath()
+	if err != nil {
+		log.Fatalf("Cannot determine state file location: %v", err)
+	}
+	st, err := state.Load(statePath)
+	if err != nil {
+		log.Fatalf("Cannot read state file %s: %v", statePath, err)
+	}
+
+	runOnce := func() {
+		files := make(chan string)
+		go walkSources(ctx, sources, recurseSources, files)
+
+		wg := &sync.WaitGroup{}
+		for f := range files {
+			statuses := make(map[string]chan string)
+			for _, bak := range backupers {
+				wg.Add(1)
+				out := make(chan string)
+				statuses[bak.label] = out
+				key := state.Key(bak.name, bucket, path)
+				go upload(ctx, bak.svc, f, path+f, key, st, out, wg)
+			}
 			wg.Add(1)
-			status := make(chan string)
-			statuses[name] = status
-			go upload(ctx, bak, f, path+f, status, wg)
+			go status(ctx, f, statuses, wg)
+			wg.Wait()
+
+			// Save after every file rather than once at the end of the
+			// batch, so a crash partway through a large recursive backup
+			// does not discard bookkeeping for files already uploaded.
+			if err := st.Save(); err != nil {
+				log.Printf("Cannot save state file %s: %v\n", statePath, err)
+			}
 		}
-		wg.Add(1)
-		go status(ctx, f, statuses, wg)
-		wg.Wait()
 	}
 
+	if !*auto {
+		runOnce()
+		return
+	}
+
+	for {
+		runOnce()
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(*interval):
+		}
+	}
 }
 
 func status(ctx context.Context, file string, statuses map[string]chan string, wg *sync.WaitGroup) {
@@ -221,18 +301,31 @@ func status(ctx context.Context, file string, statuses map[string]chan string, w
 
 }
 
-func upload(ctx context.Context, worker cloud.Backuper, file string, dest string, status chan string, wg *sync.WaitGroup) {
+func upload(ctx context.Context, worker cloud.Backuper, file string, dest string, stateKey string, st *state.State, status chan string, wg *sync.WaitGroup) {
 	defer func() {
 		close(status)
 	}()
 	defer wg.Done()
 	status <- "."
-	err := worker.Upload(ctx, file, dest)
+
+	hash, err := state.Hash(file)
 	if err != nil {
 		status <- fmt.Sprintf("ERR ❌ : %s ", err.Error())
-	} else {
-		status <- "OK ✅ "
+		return
+	}
+
+	if st.Unchanged(stateKey, file, hash) {
+		status <- "SKIP ⏭ "
+		return
 	}
+
+	if err := worker.Upload(ctx, file, dest); err != nil {
+		status <- fmt.Sprintf("ERR ❌ : %s ", err.Error())
+		return
+	}
+
+	st.Record(stateKey, file, hash)
+	status <- "OK ✅ "
 }
 
 func walkSources(ctx context.Context, sources []string, recursive bool, files chan string) {
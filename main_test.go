@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/antontsv/backup/cloud"
 	"github.com/fatih/color"
 )
 
@@ -39,19 +40,21 @@ func TestDestParsing(t *testing.T) {
 func TestLabels(t *testing.T) {
 	color.NoColor = true
 	type testCase struct {
-		name string
-		f    func() string
+		name     string
+		provider string
 	}
 
 	tests := []testCase{
-		{"Google", googlePrint},
-		{"Amazon", amazonPrint},
+		{"Google", "google"},
+		{"Amazon", "amazon"},
+		{"Backblaze", "backblaze"},
+		{"Azure", "azure"},
 	}
 
 	for _, tc := range tests {
 		t.Run(fmt.Sprintf("Label for %s", tc.name), func(t *testing.T) {
-			if tc.f() != tc.name {
-				t.Errorf("expected '%s', got '%s'", tc.name, tc.f())
+			if got := cloud.ProviderLabel(tc.provider); got != tc.name {
+				t.Errorf("expected '%s', got '%s'", tc.name, got)
 			}
 		})
 	}
@@ -0,0 +1,121 @@
+// Package state tracks which files have already been uploaded to which
+// destination, so that repeated runs of the backup command (in particular
+// the -auto daemon mode) only upload files that are new or have changed.
+package state
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileRecord describes the last known upload of a single file to a
+// destination.
+type FileRecord struct {
+	Hash       string    `json:"hash"`
+	UploadedAt time.Time `json:"uploadedAt"`
+}
+
+// State is persisted under ~/.backup/state.json, keyed first by
+// "provider|bucket|dest" and then by source file path.
+type State struct {
+	mu   sync.Mutex
+	path string
+	data map[string]map[string]FileRecord
+}
+
+// Key builds the top-level state key for a provider, bucket and
+// destination path.
+func Key(provider, bucket, dest string) string {
+	return strings.Join([]string{provider, bucket, dest}, "|")
+}
+
+// DefaultPath returns the location of the state file under the user's
+// home directory, e.g. ~/.backup/state.json.
+func DefaultPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".backup", "state.json"), nil
+}
+
+// Load reads the state file at path, returning an empty State if it does
+// not yet exist.
+func Load(path string) (*State, error) {
+	s := &State{path: path, data: make(map[string]map[string]FileRecord)}
+
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &s.data); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Save writes the state file, creating its parent directory if needed.
+func (s *State) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(s.data, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.path, b, 0600)
+}
+
+// Unchanged reports whether file was already uploaded under key with the
+// given content hash.
+func (s *State) Unchanged(key, file, hash string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	files, ok := s.data[key]
+	if !ok {
+		return false
+	}
+	rec, ok := files[file]
+	return ok && rec.Hash == hash
+}
+
+// Record marks file as uploaded under key with the given content hash.
+func (s *State) Record(key, file, hash string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.data[key] == nil {
+		s.data[key] = make(map[string]FileRecord)
+	}
+	s.data[key][file] = FileRecord{Hash: hash, UploadedAt: time.Now()}
+}
+
+// Hash computes the sha256 content hash of file, used to detect whether it
+// has changed since the last upload.
+func Hash(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,84 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUnchangedAndRecord(t *testing.T) {
+	s, err := Load(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	key := Key("google", "bucket", "path")
+
+	if s.Unchanged(key, "file.txt", "hash1") {
+		t.Errorf("expected Unchanged to be false before any Record")
+	}
+
+	s.Record(key, "file.txt", "hash1")
+
+	if !s.Unchanged(key, "file.txt", "hash1") {
+		t.Errorf("expected Unchanged to be true for a matching hash after Record")
+	}
+	if s.Unchanged(key, "file.txt", "hash2") {
+		t.Errorf("expected Unchanged to be false once the file's content hash changes")
+	}
+	if s.Unchanged(Key("amazon", "bucket", "path"), "file.txt", "hash1") {
+		t.Errorf("expected Unchanged to be false under an unrelated key")
+	}
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "state.json")
+	key := Key("google", "bucket", "path")
+
+	s, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	s.Record(key, "file.txt", "hash1")
+	if err := s.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	reloaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load after Save: %v", err)
+	}
+	if !reloaded.Unchanged(key, "file.txt", "hash1") {
+		t.Errorf("expected reloaded state to remember the recorded hash")
+	}
+}
+
+func TestHash(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "file.txt")
+	if err := os.WriteFile(file, []byte("hello world"), 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	h1, err := Hash(file)
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+	h2, err := Hash(file)
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+	if h1 != h2 {
+		t.Errorf("expected Hash to be stable across calls, got %s and %s", h1, h2)
+	}
+
+	if err := os.WriteFile(file, []byte("hello world!"), 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	h3, err := Hash(file)
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+	if h3 == h1 {
+		t.Errorf("expected Hash to change when the file's content changes")
+	}
+}